@@ -0,0 +1,59 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Classmethod descriptor
+//
+// A classmethod, unlike an ordinary method, always receives the type it
+// was looked up on as its first argument rather than an instance - this
+// is what lets dict.fromkeys(...), for instance, be called either as
+// dict.fromkeys(...) or d.fromkeys(...) and see the dict type either way.
+
+package py
+
+// Classmethod wraps fn so that accessing it through a type's Dict - via
+// an instance or the type itself - binds it to the type, not the
+// instance, the way CPython's classmethod descriptor does.
+type Classmethod struct {
+	Fn  func(cls *Type, args Tuple, kwargs Dict) (Object, error)
+	Doc string
+}
+
+var ClassmethodType = NewType("classmethod", "classmethod(function) -> classmethod object\n\nConvert a function to be a class method.")
+
+// Type of this object
+func (c *Classmethod) Type() *Type {
+	return ClassmethodType
+}
+
+// M__get__ binds c to owner - the type being accessed through, whether
+// the access went through an instance or the type itself.
+func (c *Classmethod) M__get__(instance, owner Object) (Object, error) {
+	cls, ok := owner.(*Type)
+	if !ok {
+		return nil, ExceptionNewf(TypeError, "classmethod requires the owner to be a type, got %s", owner.Type().Name)
+	}
+	return &boundClassmethod{classmethod: c, cls: cls}, nil
+}
+
+// boundClassmethod is what M__get__ returns: a Classmethod bound to the
+// specific type it was looked up on, ready to be called.
+type boundClassmethod struct {
+	classmethod *Classmethod
+	cls         *Type
+}
+
+// Type of this object
+func (b *boundClassmethod) Type() *Type {
+	return ClassmethodType
+}
+
+func (b *boundClassmethod) M__call__(args Tuple, kwargs Dict) (Object, error) {
+	return b.classmethod.Fn(b.cls, args, kwargs)
+}
+
+// Make sure it satisfies the interfaces
+var _ Object = (*Classmethod)(nil)
+var _ I__get__ = (*Classmethod)(nil)
+var _ Object = (*boundClassmethod)(nil)
+var _ I__call__ = (*boundClassmethod)(nil)
@@ -0,0 +1,122 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// CompileCache memoizes Compile for repeated eval()/exec() of the same
+// source, so embedders running many short expressions (REPLs, template
+// engines, spreadsheet-style recomputation) don't pay full parse+compile
+// cost on every call.
+
+package py
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CompileCache caches the *Code produced by Compile, keyed by the
+// (source, filename, mode, flags) that produced it.
+type CompileCache interface {
+	// Get returns the cached *Code for (source, filename, mode, flags),
+	// and whether it was found.
+	Get(source, filename, mode string, flags int) (*Code, bool)
+	// Put stores code under (source, filename, mode, flags).
+	Put(source, filename, mode string, flags int, code *Code)
+}
+
+type compileCacheKey struct {
+	source, filename, mode string
+	flags                  int
+}
+
+// lruCompileCache is the default CompileCache: a fixed-capacity LRU.
+//
+// CompileCached's default cache is process-global and so reachable from
+// however many interpreters/goroutines are calling eval()/exec()
+// concurrently; mu guards entries/order against concurrent Get/Put, which
+// would otherwise race on the map and list (and can panic with "concurrent
+// map writes").
+type lruCompileCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[compileCacheKey]*list.Element
+	order    *list.List
+}
+
+type lruCompileCacheEntry struct {
+	key  compileCacheKey
+	code *Code
+}
+
+// NewLRUCompileCache returns a CompileCache retaining up to capacity
+// most-recently-used entries. A non-positive capacity is replaced with a
+// sensible default.
+func NewLRUCompileCache(capacity int) CompileCache {
+	if capacity <= 0 {
+		capacity = 256
+	}
+	return &lruCompileCache{
+		capacity: capacity,
+		entries:  make(map[compileCacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *lruCompileCache) Get(source, filename, mode string, flags int) (*Code, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := compileCacheKey{source, filename, mode, flags}
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruCompileCacheEntry).code, true
+}
+
+func (c *lruCompileCache) Put(source, filename, mode string, flags int, code *Code) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := compileCacheKey{source, filename, mode, flags}
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruCompileCacheEntry).code = code
+		c.order.MoveToFront(el)
+		return
+	}
+	el := c.order.PushFront(&lruCompileCacheEntry{key: key, code: code})
+	c.entries[key] = el
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*lruCompileCacheEntry).key)
+		}
+	}
+}
+
+// defaultCompileCache backs CompileCached when callers don't supply their
+// own cache, so the backward-compatible path still benefits from caching.
+var defaultCompileCache = NewLRUCompileCache(256)
+
+// CompileCached is Compile with memoization: repeated calls with the same
+// (src, filename, mode, flags) return the previously compiled *Code
+// instead of reparsing and recompiling. Pass a cache from
+// NewLRUCompileCache (or a custom CompileCache) to share it across many
+// callers - for instance a single cache behind thousands of
+// eval("a+b*c", ns) calls in a spreadsheet-style recomputation loop - or
+// nil to use a package-level default cache.
+func CompileCached(cache CompileCache, src, filename, mode string, flags int) (*Code, error) {
+	if cache == nil {
+		cache = defaultCompileCache
+	}
+	if code, ok := cache.Get(src, filename, mode, flags); ok {
+		return code, nil
+	}
+	obj, err := Compile(src, filename, mode, flags, true)
+	if err != nil {
+		return nil, err
+	}
+	code := obj.(*Code)
+	cache.Put(src, filename, mode, flags, code)
+	return code, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package py
+
+import "testing"
+
+func TestLRUCompileCachePutGet(t *testing.T) {
+	cache := NewLRUCompileCache(2)
+	codeA := &Code{}
+	codeB := &Code{}
+
+	cache.Put("a", "<string>", "eval", 0, codeA)
+	if got, ok := cache.Get("a", "<string>", "eval", 0); !ok || got != codeA {
+		t.Fatalf("Get(a) = %v, %v, want codeA, true", got, ok)
+	}
+	if _, ok := cache.Get("b", "<string>", "eval", 0); ok {
+		t.Fatalf("Get(b) found an entry that was never Put")
+	}
+
+	cache.Put("b", "<string>", "eval", 0, codeB)
+	if got, ok := cache.Get("b", "<string>", "eval", 0); !ok || got != codeB {
+		t.Fatalf("Get(b) = %v, %v, want codeB, true", got, ok)
+	}
+}
+
+func TestLRUCompileCacheEviction(t *testing.T) {
+	cache := NewLRUCompileCache(2)
+	codeA, codeB, codeC := &Code{}, &Code{}, &Code{}
+	cache.Put("a", "f", "eval", 0, codeA)
+	cache.Put("b", "f", "eval", 0, codeB)
+	// Touch "a" so "b" becomes the least recently used entry.
+	cache.Get("a", "f", "eval", 0)
+	cache.Put("c", "f", "eval", 0, codeC)
+
+	if _, ok := cache.Get("b", "f", "eval", 0); ok {
+		t.Fatalf("\"b\" should have been evicted as the least recently used entry")
+	}
+	if _, ok := cache.Get("a", "f", "eval", 0); !ok {
+		t.Fatalf("\"a\" should still be cached, it was touched before the eviction")
+	}
+	if _, ok := cache.Get("c", "f", "eval", 0); !ok {
+		t.Fatalf("\"c\" should be cached, it was just Put")
+	}
+}
+
+func TestCompileCachedReusesCache(t *testing.T) {
+	// Compile is only set by the compile package, which this package can't
+	// import, so stand in for it with one returning a fresh *Code per call.
+	oldCompile := Compile
+	defer func() { Compile = oldCompile }()
+	Compile = func(src, filename, mode string, flags int, dontInherit bool) (Object, error) {
+		return &Code{}, nil
+	}
+	cache := NewLRUCompileCache(8)
+	code1, err := CompileCached(cache, "1+1", "<string>", "eval", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code2, err := CompileCached(cache, "1+1", "<string>", "eval", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code1 != code2 {
+		t.Fatalf("CompileCached compiled %q twice instead of reusing the cached *Code", "1+1")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Context carries per-execution state through the VM
+//
+// It gives an embedding Go program a place to hang cancellation,
+// deadlines, instruction/allocation budgets and print()/import
+// interception hooks without resorting to global state, in the same
+// spirit as a Starlark Thread. vm.UseSandbox wires InstrLimit and
+// cancellation into the eval()/exec() builtins themselves (start-of-call
+// only - see EvalCodeWithContext's doc in vm/context.go for why; AllocLimit
+// is charged only for names copied in from a non-dict locals mapping), but
+// this tree doesn't own a bytecode dispatch loop, a print() builtin or
+// import machinery to wire Tick into every opcode or Print/Load into a
+// live call path. Print and Load are plumbing for an embedder's own
+// print()/import builtins to call WritePrint/ResolveImport through -
+// they are not reached by anything in this tree today.
+
+package py
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// ResourceError is raised when a Context's instruction or allocation
+// budget is exhausted, or its underlying context.Context is cancelled.
+var ResourceError = NewType("ResourceError", "Execution exceeded a Context's resource budget")
+
+// Context carries cancellation, resource budgets and embedder hooks
+// through a single thread of Python execution. Tick/Alloc must be called
+// by whatever does the work being bounded - the caller's bytecode
+// dispatch loop, its print()/import builtins - Context itself has no way
+// to insert those checks into code it doesn't run.
+//
+// The zero Context is ready to use: no limits are enforced and no hooks
+// are called. A nil *Context is also valid anywhere a Context is expected
+// and behaves the same way, so existing callers that don't know about
+// sandboxing can keep passing nil.
+type Context struct {
+	// Go is consulted for cancellation and deadlines. Defaults to
+	// context.Background() if left nil; see NewContext.
+	Go context.Context
+
+	// InstrLimit bounds the number of Tick() calls this thread may make;
+	// 0 means unlimited. InstrCount is incremented by Tick. Whether that
+	// amounts to "one bytecode instruction" depends on how finely the
+	// caller's dispatch loop calls Tick - this package only counts the
+	// calls it's given.
+	InstrLimit, InstrCount int64
+
+	// AllocLimit bounds the number of object allocations this thread may
+	// perform; 0 means unlimited. Callers that allocate on behalf of
+	// Python code (e.g. list/dict/str builtins) should call Alloc.
+	AllocLimit, AllocCount int64
+
+	// Print, if set, is what an embedder's print() builtin should call
+	// through (via WritePrint) instead of writing to stdout directly.
+	Print func(thread *Context, s string) error
+
+	// Load, if set, is what an embedder's import machinery should consult
+	// (via ResolveImport) with the module name being imported, before
+	// falling back to its default resolver.
+	Load func(thread *Context, module string) (Dict, error)
+
+	// Cache, if set, memoizes compilation of source passed to eval()/exec()
+	// on this thread; see CompileCached. Nil falls back to a package-level
+	// default cache.
+	Cache CompileCache
+
+	locals map[string]Object
+}
+
+// NewContext makes a new Context wrapping ctx, with no resource limits and
+// no hooks set. A nil ctx is treated as context.Background().
+func NewContext(ctx context.Context) *Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Context{Go: ctx}
+}
+
+// Tick charges one instruction against thread and reports a ResourceError
+// if thread has been cancelled or its instruction budget is exhausted. A
+// caller that owns a bytecode dispatch loop should call it at dispatch
+// boundaries and before each function call to get per-opcode enforcement;
+// package vm calls it only at the boundaries it controls (once before
+// starting an EvalCode run, and once per name copied in/out by
+// EvalCodeMapping), so it cannot interrupt an EvalCode run already in
+// progress - see EvalCodeWithContext in package vm. It is a no-op on a nil
+// thread.
+func (thread *Context) Tick() error {
+	if thread == nil {
+		return nil
+	}
+	if thread.Go != nil {
+		select {
+		case <-thread.Go.Done():
+			return ExceptionNewf(ResourceError, "context cancelled: %v", thread.Go.Err())
+		default:
+		}
+	}
+	if thread.InstrLimit > 0 {
+		thread.InstrCount++
+		if thread.InstrCount > thread.InstrLimit {
+			return ExceptionNewf(ResourceError, "instruction budget of %d exceeded", thread.InstrLimit)
+		}
+	}
+	return nil
+}
+
+// Alloc charges n allocations against thread's allocation budget and
+// reports a ResourceError if doing so would exceed it. It is a no-op on a
+// nil thread.
+func (thread *Context) Alloc(n int64) error {
+	if thread == nil {
+		return nil
+	}
+	if thread.AllocLimit > 0 {
+		thread.AllocCount += n
+		if thread.AllocCount > thread.AllocLimit {
+			return ExceptionNewf(ResourceError, "allocation budget of %d exceeded", thread.AllocLimit)
+		}
+	}
+	return nil
+}
+
+// SetLocal stores a Go-side thread-local value under key, for use by
+// custom builtins that need to stash state on the thread running them.
+// It is a no-op on a nil thread.
+func (thread *Context) SetLocal(key string, value Object) {
+	if thread == nil {
+		return
+	}
+	if thread.locals == nil {
+		thread.locals = make(map[string]Object)
+	}
+	thread.locals[key] = value
+}
+
+// GetLocal retrieves a value previously stored with SetLocal. ok is false
+// if key was never set, or thread is nil.
+func (thread *Context) GetLocal(key string) (value Object, ok bool) {
+	if thread == nil {
+		return nil, false
+	}
+	value, ok = thread.locals[key]
+	return value, ok
+}
+
+// WritePrint is what print() should call instead of writing to stdout
+// directly: it calls thread.Print if set, and otherwise writes s to
+// os.Stdout. It is a no-op-safe default on a nil thread (writes to
+// stdout), so code that doesn't care about sandboxing can ignore it.
+func (thread *Context) WritePrint(s string) error {
+	if thread != nil && thread.Print != nil {
+		return thread.Print(thread, s)
+	}
+	_, err := fmt.Fprint(os.Stdout, s)
+	return err
+}
+
+// ResolveImport is what the import machinery should consult before
+// falling back to its default resolver: it calls thread.Load if set. ok is
+// false (with a nil error) when there is no Load hook, or thread is nil,
+// telling the caller to fall back to its default module resolution.
+func (thread *Context) ResolveImport(module string) (mod Dict, ok bool, err error) {
+	if thread == nil || thread.Load == nil {
+		return Dict{}, false, nil
+	}
+	mod, err = thread.Load(thread, module)
+	if err != nil {
+		return Dict{}, false, err
+	}
+	return mod, true, nil
+}
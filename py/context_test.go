@@ -0,0 +1,137 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package py
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContextTickInstrLimit(t *testing.T) {
+	thread := &Context{InstrLimit: 2}
+	if err := thread.Tick(); err != nil {
+		t.Fatalf("Tick() 1/2 = %v, want nil", err)
+	}
+	if err := thread.Tick(); err != nil {
+		t.Fatalf("Tick() 2/2 = %v, want nil", err)
+	}
+	err := thread.Tick()
+	if err == nil {
+		t.Fatal("Tick() past InstrLimit = nil, want a ResourceError")
+	}
+	if !strings.Contains(err.Error(), "instruction budget") {
+		t.Fatalf("Tick() error = %v, want it to mention the instruction budget", err)
+	}
+}
+
+func TestContextAllocLimit(t *testing.T) {
+	thread := &Context{AllocLimit: 5}
+	if err := thread.Alloc(3); err != nil {
+		t.Fatalf("Alloc(3) = %v, want nil", err)
+	}
+	if err := thread.Alloc(2); err != nil {
+		t.Fatalf("Alloc(2) = %v, want nil (at the limit, not over it)", err)
+	}
+	err := thread.Alloc(1)
+	if err == nil {
+		t.Fatal("Alloc(1) past AllocLimit = nil, want a ResourceError")
+	}
+	if !strings.Contains(err.Error(), "allocation budget") {
+		t.Fatalf("Alloc() error = %v, want it to mention the allocation budget", err)
+	}
+}
+
+func TestContextTickCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	thread := NewContext(ctx)
+	if err := thread.Tick(); err != nil {
+		t.Fatalf("Tick() before cancel = %v, want nil", err)
+	}
+	cancel()
+	err := thread.Tick()
+	if err == nil {
+		t.Fatal("Tick() after cancel = nil, want a ResourceError")
+	}
+	if !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Tick() error = %v, want it to mention cancellation", err)
+	}
+}
+
+func TestContextNilIsNoOp(t *testing.T) {
+	var thread *Context
+	if err := thread.Tick(); err != nil {
+		t.Fatalf("nil.Tick() = %v, want nil", err)
+	}
+	if err := thread.Alloc(1 << 30); err != nil {
+		t.Fatalf("nil.Alloc() = %v, want nil", err)
+	}
+	thread.SetLocal("panics?", None) // must not panic
+	if _, ok := thread.GetLocal("panics?"); ok {
+		t.Fatal("nil.GetLocal() found a value, want ok=false")
+	}
+}
+
+func TestContextSetGetLocal(t *testing.T) {
+	thread := NewContext(nil)
+	if _, ok := thread.GetLocal("missing"); ok {
+		t.Fatal("GetLocal(\"missing\") found a value before it was ever set")
+	}
+	thread.SetLocal("key", Int(42))
+	value, ok := thread.GetLocal("key")
+	if !ok || value != Int(42) {
+		t.Fatalf("GetLocal(\"key\") = %v, %v, want 42, true", value, ok)
+	}
+}
+
+func TestContextWritePrintUsesHook(t *testing.T) {
+	var got string
+	thread := &Context{
+		Print: func(thread *Context, s string) error {
+			got = s
+			return nil
+		},
+	}
+	if err := thread.WritePrint("hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello\n" {
+		t.Fatalf("Print hook got %q, want %q", got, "hello\n")
+	}
+}
+
+func TestContextResolveImportUsesHook(t *testing.T) {
+	mod := NewDict()
+	mod.Set(String("__name__"), String("mymod"))
+	thread := &Context{
+		Load: func(thread *Context, module string) (Dict, error) {
+			if module != "mymod" {
+				t.Fatalf("Load got module %q, want \"mymod\"", module)
+			}
+			return mod, nil
+		},
+	}
+	got, ok, err := thread.ResolveImport("mymod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("ResolveImport ok = false, want true when Load is set")
+	}
+	if name, _, _ := got.Get(String("__name__")); name != String("mymod") {
+		t.Fatalf("ResolveImport returned %v, want the dict Load returned", got)
+	}
+}
+
+func TestContextResolveImportNoHook(t *testing.T) {
+	thread := NewContext(nil)
+	_, ok, err := thread.ResolveImport("mymod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("ResolveImport ok = true with no Load hook set, want false (fall back to default resolution)")
+	}
+}
@@ -9,7 +9,10 @@
 
 package py
 
-import "bytes"
+import (
+	"bytes"
+	"reflect"
+)
 
 const dictDoc = `dict() -> new empty dictionary
 dict(mapping) -> new dictionary initialized from a mapping object's
@@ -33,46 +36,304 @@ func init() {
 		if err != nil {
 			return nil, err
 		}
-		sMap := self.(Dict)
-		o := make([]Object, 0, len(sMap))
-		for k, v := range sMap {
-			o = append(o, Tuple{k, v})
+		d := self.(Dict)
+		o := make([]Object, 0, d.Len())
+		for _, e := range d.items() {
+			o = append(o, Tuple{e.key, e.value})
 		}
 		return NewIterator(o), nil
 	}, 0, "items() -> list of D's (key, value) pairs, as 2-tuples")
 
+	DictType.Dict[String("keys")] = MustNewMethod("keys", func(self Object, args Tuple) (Object, error) {
+		err := UnpackTuple(args, nil, "keys", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return NewIterator(self.(Dict).Keys()), nil
+	}, 0, "keys() -> list of D's keys")
+
+	DictType.Dict[String("values")] = MustNewMethod("values", func(self Object, args Tuple) (Object, error) {
+		err := UnpackTuple(args, nil, "values", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return NewIterator(self.(Dict).Values()), nil
+	}, 0, "values() -> list of D's values")
+
 	DictType.Dict[String("get")] = MustNewMethod("get", func(self Object, args Tuple) (Object, error) {
 		var length = len(args)
 		switch {
 		case length == 0:
-			return nil, ExceptionNewf(TypeError, "%s expected at least 1 arguments, got %d", "items()", length)
+			return nil, ExceptionNewf(TypeError, "%s expected at least 1 arguments, got %d", "get()", length)
+		case length > 2:
+			return nil, ExceptionNewf(TypeError, "%s expected at most 2 arguments, got %d", "get()", length)
+		}
+		d := self.(Dict)
+		if res, ok, err := d.Get(args[0]); err != nil {
+			return nil, err
+		} else if ok {
+			return res, nil
+		}
+		if length == 2 {
+			return args[1], nil
+		}
+		return None, nil
+	}, 0, "get(key, default) -> If there is a val corresponding to key, return val, otherwise default")
+
+	DictType.Dict[String("pop")] = MustNewMethod("pop", func(self Object, args Tuple) (Object, error) {
+		var length = len(args)
+		switch {
+		case length == 0:
+			return nil, ExceptionNewf(TypeError, "%s expected at least 1 arguments, got %d", "pop()", length)
 		case length > 2:
-			return nil, ExceptionNewf(TypeError, "%s expected at most 2 arguments, got %d", "items()", length)
+			return nil, ExceptionNewf(TypeError, "%s expected at most 2 arguments, got %d", "pop()", length)
+		}
+		d := self.(Dict)
+		if length == 2 {
+			return d.Pop(args[0], args[1])
+		}
+		return d.Pop(args[0])
+	}, 0, "pop(key[, default]) -> remove key and return its value, or default if key is not present")
+
+	DictType.Dict[String("popitem")] = MustNewMethod("popitem", func(self Object, args Tuple) (Object, error) {
+		err := UnpackTuple(args, nil, "popitem", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return self.(Dict).PopItem()
+	}, 0, "popitem() -> remove and return an arbitrary (key, value) pair")
+
+	DictType.Dict[String("setdefault")] = MustNewMethod("setdefault", func(self Object, args Tuple) (Object, error) {
+		var length = len(args)
+		switch {
+		case length == 0:
+			return nil, ExceptionNewf(TypeError, "%s expected at least 1 arguments, got %d", "setdefault()", length)
+		case length > 2:
+			return nil, ExceptionNewf(TypeError, "%s expected at most 2 arguments, got %d", "setdefault()", length)
+		}
+		d := self.(Dict)
+		def := Object(None)
+		if length == 2 {
+			def = args[1]
+		}
+		return d.SetDefault(args[0], def)
+	}, 0, "setdefault(key, default) -> D.get(key, default), also setting it if key not in D")
+
+	DictType.Dict[String("clear")] = MustNewMethod("clear", func(self Object, args Tuple) (Object, error) {
+		err := UnpackTuple(args, nil, "clear", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		d := self.(Dict)
+		for k := range d {
+			delete(d, k)
+		}
+		return None, nil
+	}, 0, "clear() -> remove all items from D")
+
+	DictType.Dict[String("copy")] = MustNewMethod("copy", func(self Object, args Tuple) (Object, error) {
+		err := UnpackTuple(args, nil, "copy", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return self.(Dict).Copy(), nil
+	}, 0, "copy() -> a shallow copy of D")
+
+	DictType.Dict[String("update")] = MustNewMethod("update", func(self Object, args Tuple, kwargs Dict) (Object, error) {
+		if len(args) > 1 {
+			return nil, ExceptionNewf(TypeError, "update expected at most 1 arguments, got %d", len(args))
+		}
+		d := self.(Dict)
+		if len(args) == 1 {
+			if err := d.updateFrom(args[0]); err != nil {
+				return nil, err
+			}
+		}
+		for _, e := range kwargs.items() {
+			if err := d.Set(e.key, e.value); err != nil {
+				return nil, err
+			}
+		}
+		return None, nil
+	}, 0, "update([other], **kwargs) -> merge other (a dict or an iterable of (key, value) pairs) and kwargs into D")
+
+	DictType.Dict[String("fromkeys")] = &Classmethod{
+		Fn: func(cls *Type, args Tuple, kwargs Dict) (Object, error) {
+			var iterable, value Object
+			value = None
+			err := UnpackTuple(args, nil, "fromkeys", 1, 2, &iterable, &value)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range kwargs.items() {
+				name, ok := e.key.(String)
+				if !ok || name != "value" {
+					return nil, ExceptionNewf(TypeError, "fromkeys() got an unexpected keyword argument '%v'", e.key)
+				}
+				if len(args) > 1 {
+					return nil, ExceptionNewf(TypeError, "fromkeys() got multiple values for argument 'value'")
+				}
+				value = e.value
+			}
+			return FromKeys(iterable, value)
+		},
+		Doc: "fromkeys(iterable, value=None) -> a new dict with keys from iterable and values set to value",
+	}
+}
+
+// FromKeys returns a new Dict with a key for every item of iterable, each
+// set to value, as dict.fromkeys does.
+func FromKeys(iterable, value Object) (Dict, error) {
+	d := NewDict()
+	iter, err := Iter(iterable)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		key, err := Next(iter)
+		if err == StopIteration {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if err := d.Set(key, value); err != nil {
+			return nil, err
 		}
-		sMap := self.(Dict)
-		if str, ok := args[0].(String); ok {
-			if res, ok := sMap[str]; ok {
-				return res, nil
+	}
+	return d, nil
+}
+
+// Pop removes key from d and returns its value. If key isn't present, def
+// (if given, at most one value) is returned instead; with no def, a
+// KeyError is returned, as dict.pop does.
+func (d Dict) Pop(key Object, def ...Object) (Object, error) {
+	res, ok, err := d.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if len(def) > 0 {
+			return def[0], nil
+		}
+		return nil, ExceptionNewf(KeyError, "%v", key)
+	}
+	if _, err := d.Del(key); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PopItem removes and returns an arbitrary (key, value) pair from d, or a
+// KeyError if d is empty, as dict.popitem does.
+func (d Dict) PopItem() (Object, error) {
+	for k, v := range d {
+		if ov, ok := v.(dictOverflow); ok {
+			e := ov.entries[0]
+			rest := ov.entries[1:]
+			if len(rest) == 0 {
+				delete(d, k)
+			} else {
+				d[k] = dictOverflow{entries: rest}
 			}
+			return Tuple{e.key, e.value}, nil
+		}
+		delete(d, k)
+		return Tuple{k, v}, nil
+	}
+	return nil, ExceptionNewf(KeyError, "popitem(): dictionary is empty")
+}
 
-			switch length {
-			case 2:
-				return args[1], nil
-			default:
-				return None, nil
+// SetDefault returns the value stored for key, setting it to def first if
+// key isn't already present, as dict.setdefault does.
+func (d Dict) SetDefault(key, def Object) (Object, error) {
+	res, ok, err := d.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return res, nil
+	}
+	if err := d.Set(key, def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// updateFrom merges other (a Dict, or an iterable of (key, value) pairs)
+// into d, as dict.update does.
+func (d Dict) updateFrom(other Object) error {
+	if o, ok := other.(Dict); ok {
+		for _, e := range o.items() {
+			if err := d.Set(e.key, e.value); err != nil {
+				return err
 			}
 		}
-		return nil, ExceptionNewf(KeyError, "%v", args[0])
-	}, 0, "gets(key, default) -> If there is a val corresponding to key, return val, otherwise default")
+		return nil
+	}
+	iter, err := Iter(other)
+	if err != nil {
+		return err
+	}
+	for {
+		item, err := Next(iter)
+		if err == StopIteration {
+			break
+		} else if err != nil {
+			return err
+		}
+		pair, ok := item.(Tuple)
+		if !ok || len(pair) != 2 {
+			return ExceptionNewf(ValueError, "dictionary update sequence element is not a 2-tuple")
+		}
+		if err := d.Set(pair[0], pair[1]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// String to object dictionary
+// dictEntry is one (key, value) pair held in a dictOverflow bucket.
+type dictEntry struct {
+	key   Object
+	value Object
+}
+
+// dictOverflow parks every entry whose key hashes to the same int64 but
+// isn't itself comparable as a Go value (Tuple, since a Go slice can't be
+// a map key), so Dict can store it as an ordinary value under a synthetic
+// overflowKey instead of needing a second field to hold it. It's never
+// returned to Python code - Get/Set/Del/items/Keys/Values unwrap it
+// transparently - so it only needs to satisfy Object, not behave like a
+// real dict value.
+type dictOverflow struct {
+	entries []dictEntry
+}
+
+func (dictOverflow) Type() *Type { return nil }
+
+// overflowKey is the Go map key a dictOverflow is filed under: Hash(key)
+// for whatever Python key landed there. Ties between different keys that
+// happen to hash the same are broken by the Eq scan over entries, the way
+// a real hash table's chaining would.
+type overflowKey int64
+
+func (overflowKey) Type() *Type { return nil }
+
+// Dict is the python dict type.
 //
-// Used for variables etc where the keys can only be strings
+// It's a plain Go map so that the common case - string keys, as used for
+// every type's method table (DictType.Dict[String("items")] = ... above,
+// and the same pattern throughout the rest of the package) - is indexed,
+// ranged and deleted from directly with no indirection. Arbitrary
+// hashable Python keys (ints, tuples, bools, ...) are supported on top of
+// that: canonicalKey normalizes keys that Python considers hash-equal
+// but Go would treat as distinct (True and 1, 1.0 and 1) to the same Go
+// value, and keys that aren't comparable as Go values at all (Tuple) are
+// filed under a dictOverflow - see canonicalKey and dictOverflow.
 type Dict map[Object]Object
 
-// Type of this StringDict object
-func (o Dict) Type() *Type {
+// Type of this Dict object
+func (d Dict) Type() *Type {
 	return DictType
 }
 
@@ -101,10 +362,202 @@ func DictCheck(obj Object) (Dict, error) {
 	return DictCheckExact(obj)
 }
 
+// canonicalKey returns the Go value Dict uses as the real map key for a
+// Python key, and whether key needs to be filed away in a dictOverflow
+// because it isn't itself comparable as a Go value.
+//
+// Scalars that Python considers hash-equal but that are distinct Go
+// values are normalized to one of them first (bool -> int, an integral
+// float -> int), so that e.g. d[1] and d[True] land on the same entry,
+// mirroring hash(1) == hash(True) in CPython.
+func canonicalKey(key Object) (normalized Object, needsOverflow bool) {
+	switch x := key.(type) {
+	case Bool:
+		if x {
+			return Int(1), false
+		}
+		return Int(0), false
+	case Float:
+		if i := int64(x); Float(i) == x {
+			return Int(i), false
+		}
+		return key, false
+	}
+	t := reflect.TypeOf(key)
+	if t == nil || t.Comparable() {
+		return key, false
+	}
+	return key, true
+}
+
+// Len returns the number of entries in the dict
+func (d Dict) Len() int {
+	n := 0
+	for _, v := range d {
+		if ov, ok := v.(dictOverflow); ok {
+			n += len(ov.entries)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// items returns every (key, value) pair in the dict, in unspecified order
+func (d Dict) items() []dictEntry {
+	items := make([]dictEntry, 0, len(d))
+	for k, v := range d {
+		if ov, ok := v.(dictOverflow); ok {
+			items = append(items, ov.entries...)
+			continue
+		}
+		items = append(items, dictEntry{key: k, value: v})
+	}
+	return items
+}
+
+// Keys returns the dict's keys, in unspecified order
+func (d Dict) Keys() []Object {
+	keys := make([]Object, 0, len(d))
+	for k, v := range d {
+		if ov, ok := v.(dictOverflow); ok {
+			for _, e := range ov.entries {
+				keys = append(keys, e.key)
+			}
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Values returns the dict's values, in unspecified order
+func (d Dict) Values() []Object {
+	values := make([]Object, 0, len(d))
+	for _, v := range d {
+		if ov, ok := v.(dictOverflow); ok {
+			for _, e := range ov.entries {
+				values = append(values, e.value)
+			}
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// Get returns the value stored for key and whether it was found
+func (d Dict) Get(key Object) (value Object, ok bool, err error) {
+	norm, overflow := canonicalKey(key)
+	if !overflow {
+		v, found := d[norm]
+		return v, found, nil
+	}
+	h, err := Hash(key)
+	if err != nil {
+		return nil, false, err
+	}
+	ov, found := d[overflowKey(h)]
+	if !found {
+		return nil, false, nil
+	}
+	for _, e := range ov.(dictOverflow).entries {
+		eq, err := Eq(e.key, key)
+		if err != nil {
+			return nil, false, err
+		}
+		if eq == True {
+			return e.value, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// Set stores value under key, replacing any existing entry for key. Set
+// is a silent no-op on a nil Dict (the zero value, which DictCheckExact
+// also returns alongside its error), which would otherwise panic on
+// assignment the way writing to any nil Go map does.
+func (d Dict) Set(key, value Object) error {
+	if d == nil {
+		return nil
+	}
+	norm, overflow := canonicalKey(key)
+	if !overflow {
+		d[norm] = value
+		return nil
+	}
+	h, err := Hash(key)
+	if err != nil {
+		return err
+	}
+	ok := overflowKey(h)
+	var entries []dictEntry
+	if existing, found := d[ok]; found {
+		entries = existing.(dictOverflow).entries
+		for i, e := range entries {
+			eq, err := Eq(e.key, key)
+			if err != nil {
+				return err
+			}
+			if eq == True {
+				entries[i].value = value
+				d[ok] = dictOverflow{entries: entries}
+				return nil
+			}
+		}
+	}
+	d[ok] = dictOverflow{entries: append(entries, dictEntry{key: key, value: value})}
+	return nil
+}
+
+// Del removes the entry for key, if any, reporting whether it was present
+func (d Dict) Del(key Object) (bool, error) {
+	norm, overflow := canonicalKey(key)
+	if !overflow {
+		_, found := d[norm]
+		if found {
+			delete(d, norm)
+		}
+		return found, nil
+	}
+	h, err := Hash(key)
+	if err != nil {
+		return false, err
+	}
+	ok := overflowKey(h)
+	existing, found := d[ok]
+	if !found {
+		return false, nil
+	}
+	entries := existing.(dictOverflow).entries
+	for i, e := range entries {
+		eq, err := Eq(e.key, key)
+		if err != nil {
+			return false, err
+		}
+		if eq == True {
+			entries = append(entries[:i], entries[i+1:]...)
+			if len(entries) == 0 {
+				delete(d, ok)
+			} else {
+				d[ok] = dictOverflow{entries: entries}
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // Copy a dictionary
 func (d Dict) Copy() Dict {
 	e := make(Dict, len(d))
 	for k, v := range d {
+		if ov, ok := v.(dictOverflow); ok {
+			entries := make([]dictEntry, len(ov.entries))
+			copy(entries, ov.entries)
+			e[k] = dictOverflow{entries: entries}
+			continue
+		}
 		e[k] = v
 	}
 	return e
@@ -118,15 +571,15 @@ func (a Dict) M__repr__() (Object, error) {
 	var out bytes.Buffer
 	out.WriteRune('{')
 	spacer := false
-	for key, value := range a {
+	for _, e := range a.items() {
 		if spacer {
 			out.WriteString(", ")
 		}
-		keyStr, err := ReprAsString(key)
+		keyStr, err := ReprAsString(e.key)
 		if err != nil {
 			return nil, err
 		}
-		valueStr, err := ReprAsString(value)
+		valueStr, err := ReprAsString(e.value)
 		if err != nil {
 			return nil, err
 		}
@@ -141,30 +594,39 @@ func (a Dict) M__repr__() (Object, error) {
 
 // Returns a list of keys from the dict
 func (d Dict) M__iter__() (Object, error) {
-	o := make([]Object, 0, len(d))
-	for k := range d {
-		o = append(o, k)
-	}
-	return NewIterator(o), nil
+	return NewIterator(d.Keys()), nil
+}
+
+func (d Dict) M__len__() (Object, error) {
+	return Int(d.Len()), nil
 }
 
 func (d Dict) M__getitem__(key Object) (Object, error) {
-	str, ok := key.(String)
+	res, ok, err := d.Get(key)
+	if err != nil {
+		return nil, err
+	}
 	if ok {
-		res, ok := d[str]
-		if ok {
-			return res, nil
-		}
+		return res, nil
 	}
 	return nil, ExceptionNewf(KeyError, "%v", key)
 }
 
 func (d Dict) M__setitem__(key, value Object) (Object, error) {
-	//str, ok := key.(String)
-	//if !ok {
-	//	return nil, ExceptionNewf(KeyError, "FIXME can only have string keys!: %v", key)
-	//}
-	d[key] = value
+	if err := d.Set(key, value); err != nil {
+		return nil, err
+	}
+	return None, nil
+}
+
+func (d Dict) M__delitem__(key Object) (Object, error) {
+	ok, err := d.Del(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ExceptionNewf(KeyError, "%v", key)
+	}
 	return None, nil
 }
 
@@ -173,15 +635,18 @@ func (a Dict) M__eq__(other Object) (Object, error) {
 	if !ok {
 		return NotImplemented, nil
 	}
-	if len(a) != len(b) {
+	if a.Len() != b.Len() {
 		return False, nil
 	}
-	for k, av := range a {
-		bv, ok := b[k]
+	for _, e := range a.items() {
+		bv, ok, err := b.Get(e.key)
+		if err != nil {
+			return nil, err
+		}
 		if !ok {
 			return False, nil
 		}
-		res, err := Eq(av, bv)
+		res, err := Eq(e.value, bv)
 		if err != nil {
 			return nil, err
 		}
@@ -206,13 +671,12 @@ func (a Dict) M__ne__(other Object) (Object, error) {
 	return True, nil
 }
 
-func (a Dict) M__contains__(other Object) (Object, error) {
-	key, ok := other.(String)
-	if !ok {
-		return nil, ExceptionNewf(KeyError, "FIXME can only have string keys!: %v", key)
+func (a Dict) M__contains__(key Object) (Object, error) {
+	_, ok, err := a.Get(key)
+	if err != nil {
+		return nil, err
 	}
-
-	if _, ok := a[key]; ok {
+	if ok {
 		return True, nil
 	}
 	return False, nil
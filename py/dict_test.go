@@ -0,0 +1,166 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package py
+
+import "testing"
+
+func TestDictIntBoolKeysCollide(t *testing.T) {
+	d := NewDict()
+	if err := d.Set(Int(1), String("a")); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := d.Get(Bool(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || v != String("a") {
+		t.Fatalf("Get(True) = %v, %v, want \"a\", true", v, ok)
+	}
+	if d.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1 since 1 and True are the same key", d.Len())
+	}
+}
+
+func TestDictEqCrossType(t *testing.T) {
+	a := NewDict()
+	a.Set(Int(1), String("a"))
+	b := NewDict()
+	b.Set(Bool(true), String("a"))
+	eq, err := a.M__eq__(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eq != True {
+		t.Fatalf("{1:'a'} == {True:'a'} = %v, want True", eq)
+	}
+}
+
+func TestDictTupleKeys(t *testing.T) {
+	d := NewDict()
+	k1 := Tuple{Int(1), Int(2)}
+	k2 := Tuple{Int(1), Int(3)}
+	if err := d.Set(k1, String("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set(k2, String("y")); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := d.Get(Tuple{Int(1), Int(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || v != String("x") {
+		t.Fatalf("Get((1, 2)) = %v, %v, want \"x\", true", v, ok)
+	}
+	if d.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", d.Len())
+	}
+}
+
+func TestDictPop(t *testing.T) {
+	d := NewDict()
+	d.Set(String("k"), Int(1))
+	v, err := d.Pop(String("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != Int(1) {
+		t.Fatalf("Pop(\"k\") = %v, want 1", v)
+	}
+	if _, ok, _ := d.Get(String("k")); ok {
+		t.Fatalf("key still present after Pop")
+	}
+	if _, err := d.Pop(String("missing")); err == nil {
+		t.Fatalf("Pop of missing key with no default should error")
+	}
+	v, err = d.Pop(String("missing"), String("default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != String("default") {
+		t.Fatalf("Pop(\"missing\", \"default\") = %v, want \"default\"", v)
+	}
+}
+
+func TestDictPopItem(t *testing.T) {
+	d := NewDict()
+	d.Set(String("k"), Int(1))
+	res, err := d.PopItem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, ok := res.(Tuple)
+	if !ok || len(pair) != 2 || pair[0] != String("k") || pair[1] != Int(1) {
+		t.Fatalf("PopItem() = %v, want (\"k\", 1)", res)
+	}
+	if d.Len() != 0 {
+		t.Fatalf("Len() = %d after PopItem, want 0", d.Len())
+	}
+	if _, err := d.PopItem(); err == nil {
+		t.Fatalf("PopItem() on empty dict should error")
+	}
+}
+
+func TestDictSetDefault(t *testing.T) {
+	d := NewDict()
+	v, err := d.SetDefault(String("k"), Int(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != Int(9) {
+		t.Fatalf("SetDefault(\"k\", 9) = %v, want 9", v)
+	}
+	v, err = d.SetDefault(String("k"), Int(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != Int(9) {
+		t.Fatalf("SetDefault(\"k\", 100) on existing key = %v, want 9", v)
+	}
+}
+
+func TestDictUpdate(t *testing.T) {
+	d := NewDict()
+	d.Set(String("a"), Int(1))
+	other := NewDict()
+	other.Set(String("a"), Int(2))
+	other.Set(String("b"), Int(3))
+	if err := d.updateFrom(other); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := d.Get(String("a")); v != Int(2) {
+		t.Fatalf("a = %v after updateFrom, want 2", v)
+	}
+	if v, _, _ := d.Get(String("b")); v != Int(3) {
+		t.Fatalf("b = %v after updateFrom, want 3", v)
+	}
+}
+
+func TestDictFromKeys(t *testing.T) {
+	d, err := FromKeys(NewIterator([]Object{String("a"), String("b")}), Int(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := d.Get(String("a")); v != Int(0) {
+		t.Fatalf("d[\"a\"] = %v, want 0", v)
+	}
+	if v, _, _ := d.Get(String("b")); v != Int(0) {
+		t.Fatalf("d[\"b\"] = %v, want 0", v)
+	}
+}
+
+func TestDictFromKeysIsClassmethod(t *testing.T) {
+	fromkeys, ok := DictType.Dict[String("fromkeys")].(*Classmethod)
+	if !ok {
+		t.Fatalf("DictType.Dict[\"fromkeys\"] is %T, want *Classmethod", DictType.Dict[String("fromkeys")])
+	}
+	bound, err := fromkeys.M__get__(None, DictType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bound.(I__call__); !ok {
+		t.Fatalf("bound fromkeys is %T, want I__call__", bound)
+	}
+}
@@ -73,7 +73,7 @@ func NewFunction(code *Code, globals Dict, qualname string) *Function {
 	}
 
 	// __module__: If module name is in globals, use it. Otherwise, use None.
-	if moduleobj, ok := globals[String("__name__")]; ok {
+	if moduleobj, ok, _ := globals.Get(String("__name__")); ok {
 		module = moduleobj
 	}
 
@@ -88,7 +88,7 @@ func NewFunction(code *Code, globals Dict, qualname string) *Function {
 		Name:     code.Name,
 		Doc:      doc,
 		Module:   module,
-		Dict:     make(Dict),
+		Dict:     NewDict(),
 	}
 }
 
@@ -109,8 +109,151 @@ func (f *Function) M__get__(instance, owner Object) (Object, error) {
 	return f, nil
 }
 
+// Copy returns a new Function sharing f's Code and Globals but with
+// independent Dict and Annotations, matching CPython's copy.copy on a
+// function object (used by decorators and memoization wrappers that want
+// to rename a function or give it its own __dict__ without mutating the
+// original). Globals is deliberately not copied: it's the module
+// namespace the function runs against, and CPython's function objects
+// built from the same code share it too - a detached copy wouldn't see
+// later module-level changes, and a recursive call by name inside the
+// function would resolve against a stale snapshot instead of the module's
+// real globals.
+func (f *Function) Copy() *Function {
+	g := *f
+	g.Dict = f.Dict.Copy()
+	if f.Annotations != nil {
+		g.Annotations = f.Annotations.Copy()
+	}
+	return &g
+}
+
+const functionNewDoc = `function(code, globals, name=None, argdefs=None, closure=None)
+
+Create a function object from a code object and a dictionary.
+The optional name string overrides the name from the code object.
+The optional argdefs tuple specifies the default argument values.
+The optional closure tuple supplies the bindings for free variables.`
+
+// functionNewArgNames are the positional names of FunctionType.__new__'s
+// parameters, in order, used to resolve keyword arguments against their
+// positional slot.
+var functionNewArgNames = [...]string{"code", "globals", "name", "argdefs", "closure"}
+
 // Properties
 func init() {
+	FunctionType.Dict[String("__new__")] = MustNewMethod("__new__", func(self Object, args Tuple, kwargs Dict) (Object, error) {
+		var slots [len(functionNewArgNames)]Object
+		for i, v := range args {
+			if i >= len(slots) {
+				return nil, ExceptionNewf(TypeError, "function() takes at most %d arguments (%d given)", len(slots), len(args))
+			}
+			slots[i] = v
+		}
+		for _, key := range kwargs.Keys() {
+			name, ok := key.(String)
+			if !ok {
+				return nil, ExceptionNewf(TypeError, "keywords must be strings")
+			}
+			idx := -1
+			for i, argName := range functionNewArgNames {
+				if string(name) == argName {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				return nil, ExceptionNewf(TypeError, "function() got an unexpected keyword argument '%s'", name)
+			}
+			if idx < len(args) {
+				return nil, ExceptionNewf(TypeError, "function() got multiple values for argument '%s'", name)
+			}
+			value, _, _ := kwargs.Get(name)
+			slots[idx] = value
+		}
+		if slots[0] == nil || slots[1] == nil {
+			return nil, ExceptionNewf(TypeError, "function() requires at least 2 arguments")
+		}
+		code, globals := slots[0], slots[1]
+		name := Object(None)
+		if slots[2] != nil {
+			name = slots[2]
+		}
+		argdefs := Object(None)
+		if slots[3] != nil {
+			argdefs = slots[3]
+		}
+		closure := Object(None)
+		if slots[4] != nil {
+			closure = slots[4]
+		}
+		codeObj, ok := code.(*Code)
+		if !ok {
+			return nil, ExceptionNewf(TypeError, "arg 1 must be code, not %s", code.Type().Name)
+		}
+		globalsDict, ok := globals.(Dict)
+		if !ok {
+			return nil, ExceptionNewf(TypeError, "arg 2 must be dict, not %s", globals.Type().Name)
+		}
+		qualname := ""
+		if name != None {
+			nameStr, ok := name.(String)
+			if !ok {
+				return nil, ExceptionNewf(TypeError, "arg 3 must be str, not %s", name.Type().Name)
+			}
+			qualname = string(nameStr)
+		}
+		fn := NewFunction(codeObj, globalsDict, qualname)
+		if qualname != "" {
+			fn.Name = qualname
+		}
+		if argdefs != None {
+			defaults, ok := argdefs.(Tuple)
+			if !ok {
+				return nil, ExceptionNewf(TypeError, "arg 4 must be tuple, not %s", argdefs.Type().Name)
+			}
+			fn.Defaults = defaults
+		}
+		nfree := len(codeObj.Freevars)
+		if closure == None {
+			if nfree > 0 {
+				return nil, ExceptionNewf(TypeError, "%s() requires a code object with 0 free vars, not %d", qualname, nfree)
+			}
+		} else {
+			closureTuple, ok := closure.(Tuple)
+			if !ok {
+				return nil, ExceptionNewf(TypeError, "arg 5 must be tuple, not %s", closure.Type().Name)
+			}
+			if len(closureTuple) != nfree {
+				return nil, ExceptionNewf(TypeError, "%s() requires a code object with %d free vars, not %d", qualname, len(closureTuple), nfree)
+			}
+			fn.Closure = closureTuple
+		}
+		return fn, nil
+	}, 0, functionNewDoc)
+
+	FunctionType.Dict[String("__copy__")] = MustNewMethod("__copy__", func(self Object, args Tuple) (Object, error) {
+		err := UnpackTuple(args, nil, "__copy__", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return self.(*Function).Copy(), nil
+	}, 0, "__copy__() -> a copy of the function, sharing the same code")
+
+	FunctionType.Dict[String("__globals__")] = &Property{
+		Fget: func(self Object) (Object, error) {
+			return self.(*Function).Globals, nil
+		},
+	}
+	FunctionType.Dict[String("__closure__")] = &Property{
+		Fget: func(self Object) (Object, error) {
+			f := self.(*Function)
+			if f.Closure == nil {
+				return None, nil
+			}
+			return f.Closure, nil
+		},
+	}
 	FunctionType.Dict[String("__code__")] = &Property{
 		Fget: func(self Object) (Object, error) {
 			return self.(*Function).Code, nil
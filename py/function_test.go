@@ -0,0 +1,53 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package py
+
+import "testing"
+
+func TestNewFunctionModuleFromGlobals(t *testing.T) {
+	globals := NewDict()
+	globals.Set(String("__name__"), String("mymod"))
+	f := NewFunction(&Code{}, globals, "")
+	if f.Module != String("mymod") {
+		t.Fatalf("Module = %v, want \"mymod\"", f.Module)
+	}
+}
+
+func TestNewFunctionModuleDefaultsToNone(t *testing.T) {
+	f := NewFunction(&Code{}, NewDict(), "")
+	if f.Module != None {
+		t.Fatalf("Module = %v, want None when __name__ isn't in globals", f.Module)
+	}
+}
+
+func TestFunctionCopy(t *testing.T) {
+	f := &Function{
+		Name:        "f",
+		Globals:     NewDict(),
+		Dict:        NewDict(),
+		Annotations: NewDict(),
+	}
+	f.Globals.Set(String("x"), Int(1))
+	f.Dict.Set(String("attr"), Int(2))
+	f.Annotations.Set(String("x"), Int(3))
+
+	g := f.Copy()
+	if g == f {
+		t.Fatalf("Copy() returned the same *Function")
+	}
+
+	g.Globals.Set(String("y"), Int(99))
+	if _, ok, _ := f.Globals.Get(String("y")); !ok {
+		t.Fatalf("Copy() gave the copy independent Globals; CPython shares the module namespace")
+	}
+	g.Dict.Set(String("attr2"), Int(100))
+	if _, ok, _ := f.Dict.Get(String("attr2")); ok {
+		t.Fatalf("mutating the copy's Dict affected the original")
+	}
+	g.Annotations.Set(String("y"), Int(4))
+	if _, ok, _ := f.Annotations.Get(String("y")); ok {
+		t.Fatalf("mutating the copy's Annotations affected the original")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Hashing of python objects, used by Dict to key its buckets
+
+package py
+
+import "hash/fnv"
+
+// I__hash__ is implemented by objects which define their own __hash__
+type I__hash__ interface {
+	M__hash__() (Object, error)
+}
+
+// Hash returns a hash of obj suitable for use as a Dict key, following
+// CPython's rule that objects which compare equal must hash equal - in
+// particular Hash(Int(1)) == Hash(Bool(true)) == Hash(Float(1.0)), since
+// 1 == True == 1.0.
+//
+// Objects implementing I__hash__ are dispatched to it; the handful of
+// builtin immutable types are hashed directly; everything else (lists,
+// dicts, and any type that doesn't define __hash__) is unhashable and
+// returns a TypeError, exactly as CPython's hash() does.
+func Hash(obj Object) (int64, error) {
+	switch x := obj.(type) {
+	case I__hash__:
+		res, err := x.M__hash__()
+		if err != nil {
+			return 0, err
+		}
+		i, ok := res.(Int)
+		if !ok {
+			return 0, ExceptionNewf(TypeError, "__hash__ method should return an integer")
+		}
+		return int64(i), nil
+	case String:
+		return hashString(string(x)), nil
+	case Bytes:
+		return hashString(string(x)), nil
+	case Bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case Int:
+		return int64(x), nil
+	case Float:
+		return int64(x), nil
+	case NoneType:
+		return 0, nil
+	case Tuple:
+		// Order sensitive combination (hash(()) must differ from
+		// hash((0,))), following CPython's xxHash-derived tuple hash in
+		// spirit if not in bit-pattern.
+		h := int64(0x345678)
+		for _, v := range x {
+			vh, err := Hash(v)
+			if err != nil {
+				return 0, err
+			}
+			h = (h ^ vh) * 1000003
+		}
+		return h, nil
+	}
+	return 0, ExceptionNewf(TypeError, "unhashable type: '%s'", obj.Type().Name)
+}
+
+func hashString(s string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return int64(h.Sum64())
+}
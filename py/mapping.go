@@ -0,0 +1,37 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Mapping protocol
+//
+// CPython allows any mapping (not just dict) to be used as the locals
+// namespace for eval()/exec() - class bodies, exec(code, globals,
+// SomeMapping()) and wrappers like collections.ChainMap all rely on this.
+// globals, by contrast, must remain a concrete dict.
+
+package py
+
+// Mapping is the protocol eval()/exec() require of a locals argument.
+//
+// M__iter__ (over keys) and M__delitem__ are part of the protocol, not
+// optional extras: eval()/exec() run against a copy of locals' bindings
+// (see vm.EvalCodeMapping) and need to enumerate what's there going in and
+// delete what the run removed going out - a mapping that only supports
+// get/set couldn't have either observed or reported correctly.
+type Mapping interface {
+	M__getitem__(key Object) (Object, error)
+	M__setitem__(key, value Object) (Object, error)
+	M__delitem__(key Object) (Object, error)
+	M__contains__(key Object) (Object, error)
+	M__iter__() (Object, error)
+}
+
+// Dict satisfies Mapping directly, so the common case (a plain dict passed
+// as locals) is used as-is with no wrapping.
+var _ Mapping = Dict{}
+
+// AsMapping reports whether obj implements Mapping, returning it if so.
+func AsMapping(obj Object) (Mapping, bool) {
+	m, ok := obj.(Mapping)
+	return m, ok
+}
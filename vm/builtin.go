@@ -30,19 +30,24 @@ func builtinEvalOrExec(self py.Object, args py.Tuple, kwargs, currentLocals, cur
 	} else if locals == py.None {
 		locals = globals
 	}
-	// FIXME this can be a mapping too
+	// globals must be a concrete dict, as CPython requires, but locals may
+	// be any mapping (a dict, a class namespace, a ChainMap, ...).
 	globalsDict, err := py.DictCheck(globals)
 	if err != nil {
 		return nil, py.ExceptionNewf(py.TypeError, "globals must be a dict")
 	}
-	localsDict, err := py.DictCheck(locals)
-	if err != nil {
-		return nil, py.ExceptionNewf(py.TypeError, "locals must be a dict")
+	localsMapping, ok := py.AsMapping(locals)
+	if !ok {
+		return nil, py.ExceptionNewf(py.TypeError, "locals must be a mapping")
 	}
 
 	// Set __builtins__ if not set
-	if _, ok := globalsDict[py.String("__builtins__")]; !ok {
-		globalsDict[py.String("__builtins__")] = builtins
+	if _, ok, err := globalsDict.Get(py.String("__builtins__")); err != nil {
+		return nil, err
+	} else if !ok {
+		if err := globalsDict.Set(py.String("__builtins__"), builtins); err != nil {
+			return nil, err
+		}
 	}
 
 	var codeStr string
@@ -58,18 +63,27 @@ func builtinEvalOrExec(self py.Object, args py.Tuple, kwargs, currentLocals, cur
 		return nil, py.ExceptionNewf(py.TypeError, "%s() arg 1 must be a string, bytes or code object", mode)
 
 	}
+	thread := currentSandbox()
 	if code == nil {
+		var cache py.CompileCache
+		if thread != nil {
+			cache = thread.Cache
+		}
 		codeStr = strings.TrimLeft(codeStr, " \t")
-		obj, err := py.Compile(codeStr, "<string>", mode, 0, true)
+		code, err = py.CompileCached(cache, codeStr, "<string>", mode, 0)
 		if err != nil {
 			return nil, err
 		}
-		code = obj.(*py.Code)
 	}
 	if code.GetNumFree() > 0 {
 		return nil, py.ExceptionNewf(py.TypeError, "code passed to %s() may not contain free variables", mode)
 	}
-	return EvalCode(code, globalsDict, localsDict)
+	// The eval()/exec() builtins run under whatever *py.Context is
+	// currently registered with UseSandbox (nil - no limits, no hooks -
+	// if none is); that's what lets eval()/exec() calls made by
+	// untrusted Python code itself be bounded, not just the Go-level
+	// EvalCodeWithContext entry point an embedder calls directly.
+	return EvalCodeWithContext(thread, code, globalsDict, localsMapping)
 }
 
 func builtinEval(self py.Object, args py.Tuple, kwargs, currentLocals, currentGlobals, builtins py.Dict) (py.Object, error) {
@@ -0,0 +1,281 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Context-aware entry points for running code, so an embedding Go program
+// can sandbox untrusted Python without relying on global state
+
+package vm
+
+import (
+	"sync"
+
+	"github.com/go-python/gpython/py"
+)
+
+// sandboxThread is the *py.Context the eval()/exec() builtins run under;
+// see UseSandbox. nil (the default) matches the previous behaviour of
+// builtinEvalOrExec: eval()/exec() stay unsandboxed. sandboxMu guards it
+// against concurrent UseSandbox/restore calls and the read in
+// builtinEvalOrExec, which would otherwise be an unguarded data race the
+// moment two goroutines run sandboxed scripts at once - exactly the
+// concurrent-request case this feature exists for.
+var (
+	sandboxMu     sync.Mutex
+	sandboxThread *py.Context
+)
+
+// UseSandbox makes thread the *py.Context that the eval()/exec() builtins
+// (not just the Go-level EvalCodeWithContext entry point) run under, and
+// returns a restore func that puts back whatever was registered before.
+// This is how an embedder sandboxes Python-level eval()/exec() calls
+// without changing their CPython-compatible builtin signature to take a
+// thread argument:
+//
+//	restore := vm.UseSandbox(thread)
+//	defer restore()
+//	... run untrusted code that may call eval()/exec() ...
+//
+// It's package-level and global, so it only makes sense to have one
+// sandbox active at a time - two goroutines each holding their own
+// restore func will still clobber each other's thread, the later
+// UseSandbox call winning until its restore runs. sandboxMu only makes
+// that interleaving race-free to read, not correct to overlap; don't call
+// UseSandbox from concurrently-running sandboxed scripts. Like
+// EvalCodeWithContext, the enforcement this buys eval()/exec() is
+// start-of-call only (InstrLimit and cancellation checked once after
+// compiling and before running, not at each opcode; AllocLimit charged
+// only for names copied in from a locals mapping that isn't a py.Dict);
+// see EvalCodeWithContext's doc for why. The registered thread's Cache,
+// if set, is what eval()/exec() compile string and bytes source through.
+func UseSandbox(thread *py.Context) (restore func()) {
+	sandboxMu.Lock()
+	prev := sandboxThread
+	sandboxThread = thread
+	sandboxMu.Unlock()
+	return func() {
+		sandboxMu.Lock()
+		sandboxThread = prev
+		sandboxMu.Unlock()
+	}
+}
+
+// currentSandbox returns the *py.Context registered by the most recent
+// still-active UseSandbox call, race-free against concurrent
+// UseSandbox/restore calls.
+func currentSandbox() *py.Context {
+	sandboxMu.Lock()
+	defer sandboxMu.Unlock()
+	return sandboxThread
+}
+
+// EvalCodeWithContext is the context-aware counterpart of EvalCode. thread
+// supplies the context.Context used for cancellation and deadlines and the
+// instruction/allocation budgets charged by Tick/Alloc. Its Print/Load
+// hooks are not consulted here; see PrintWithContext and
+// py.Context.ResolveImport. A nil thread behaves exactly like EvalCode: no
+// limits, no hooks.
+//
+// thread.Tick is charged once up front, so an already-exhausted or
+// already-cancelled thread never starts a run - that is the only
+// granularity this package can offer. Genuinely interrupting a run in
+// progress needs every opcode dispatch to call thread.Tick, which means
+// owning the VM's bytecode dispatch loop; that loop isn't part of this
+// package (or this tree), so EvalCode itself runs to completion
+// uninterrupted once started, exactly as it does with a nil thread. Do not
+// rely on InstrLimit/AllocLimit/cancellation to bound a single EvalCode
+// call already in progress - only to refuse to start one, and (via
+// EvalCodeMapping's copy-in/copy-out) to bound the cost of a mapping with
+// many names.
+//
+// An earlier version of this function ran EvalCode on a goroutine and
+// raced it against thread.Go.Done() to return early on cancellation. That
+// doesn't stop the untrusted code - Go cannot preempt a running goroutine
+// - so the abandoned goroutine kept running to completion in the
+// background, still mutating globals/locals while the caller had already
+// moved on and could be reading or writing the same Dict: a data race on
+// top of the leaked, still-spinning goroutine. It's been removed; a
+// cancelled call now simply isn't started.
+//
+// locals may be any py.Mapping, not just a concrete py.Dict - CPython
+// allows this for class bodies, exec(code, globals, SomeMapping()) and
+// similar - with py.Dict as the fast path that runs with no extra
+// indirection. globals must remain a concrete py.Dict, as CPython requires.
+func EvalCodeWithContext(thread *py.Context, code *py.Code, globals py.Dict, locals py.Mapping) (py.Object, error) {
+	if err := thread.Tick(); err != nil {
+		return nil, err
+	}
+	localsDict, ok := locals.(py.Dict)
+	if !ok {
+		return EvalCodeMapping(thread, code, globals, locals)
+	}
+	return EvalCode(code, globals, localsDict)
+}
+
+// EvalCodeMapping is the py.Mapping-accepting counterpart of EvalCode, used
+// by EvalCodeWithContext whenever locals isn't a concrete py.Dict. Since
+// STORE_NAME et al. address frame.Locals as a concrete dict, running code
+// against an arbitrary mapping is done by copying the mapping's current
+// bindings into a temporary dict, running the code against that dict, then
+// writing the run's net effect - sets and deletes alike - back into the
+// mapping, giving it the same before/after view exec(code, globals,
+// mapping) gives it in CPython, even though individual STORE_NAMEs/
+// DELETE_NAMEs during the run aren't each forwarded to it one at a time.
+//
+// locals must implement M__iter__ over its keys; that's required by
+// py.Mapping precisely so this copy-in can't silently run code against an
+// empty shadow (and raise spurious NameErrors) just because the caller's
+// mapping doesn't support enumeration.
+func EvalCodeMapping(thread *py.Context, code *py.Code, globals py.Dict, locals py.Mapping) (py.Object, error) {
+	shadow, before, err := copyMappingIn(thread, locals)
+	if err != nil {
+		return nil, err
+	}
+	result, err := EvalCode(code, globals, shadow)
+	if writeErr := writeMappingBack(thread, locals, before, shadow); err == nil {
+		err = writeErr
+	}
+	return result, err
+}
+
+// copyMappingIn enumerates locals via M__iter__/M__getitem__ into a fresh
+// dict EvalCode can run against directly, and returns a second dict - the
+// same bindings - as the pre-run snapshot writeMappingBack diffs against.
+func copyMappingIn(thread *py.Context, locals py.Mapping) (shadow, before py.Dict, err error) {
+	shadow = py.NewDict()
+	before = py.NewDict()
+	iter, err := locals.M__iter__()
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		if err := thread.Tick(); err != nil {
+			return nil, nil, err
+		}
+		key, err := py.Next(iter)
+		if err == py.StopIteration {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		value, err := locals.M__getitem__(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := thread.Alloc(1); err != nil {
+			return nil, nil, err
+		}
+		if err := shadow.Set(key, value); err != nil {
+			return nil, nil, err
+		}
+		if err := before.Set(key, value); err != nil {
+			return nil, nil, err
+		}
+	}
+	return shadow, before, nil
+}
+
+// writeMappingBack diffs after (the shadow dict post-run) against before
+// (copyMappingIn's pre-run snapshot) and applies only the net change to
+// locals: keys whose value actually changed are M__setitem__, keys
+// present in before but missing from after are M__delitem__, and keys
+// whose value is unchanged are left alone, so a mapping with observable
+// __setitem__ side effects (a ChainMap-style wrapper, a logging mapping)
+// sees no writes for names the run didn't change.
+func writeMappingBack(thread *py.Context, locals py.Mapping, before, after py.Dict) error {
+	var err error
+	afterKeys := make(map[py.Object]bool, after.Len())
+	for _, key := range after.Keys() {
+		if tickErr := thread.Tick(); tickErr != nil {
+			if err == nil {
+				err = tickErr
+			}
+			break
+		}
+		value, _, getErr := after.Get(key)
+		if getErr != nil {
+			if err == nil {
+				err = getErr
+			}
+			continue
+		}
+		afterKeys[key] = true
+		orig, had, beforeErr := before.Get(key)
+		if beforeErr != nil {
+			if err == nil {
+				err = beforeErr
+			}
+			continue
+		}
+		if had {
+			eq, eqErr := py.Eq(orig, value)
+			if eqErr != nil {
+				if err == nil {
+					err = eqErr
+				}
+				continue
+			}
+			if eq == py.True {
+				continue
+			}
+		}
+		if _, setErr := locals.M__setitem__(key, value); setErr != nil && err == nil {
+			err = setErr
+		}
+	}
+	for _, key := range before.Keys() {
+		if afterKeys[key] {
+			continue
+		}
+		if tickErr := thread.Tick(); tickErr != nil {
+			if err == nil {
+				err = tickErr
+			}
+			break
+		}
+		if _, delErr := locals.M__delitem__(key); delErr != nil && err == nil {
+			err = delErr
+		}
+	}
+	return err
+}
+
+// EvalSourceWithContext compiles src under (filename, mode, flags) - using
+// thread's CompileCache if set, so repeated calls with the same src don't
+// pay the full parse+compile cost again - and then runs it exactly as
+// EvalCodeWithContext does. This is the entry point embedders running many
+// short expressions (REPLs, template engines, spreadsheet-style
+// recomputation) should use to get the benefit of a shared compile cache.
+func EvalSourceWithContext(thread *py.Context, src, filename, mode string, flags int, globals py.Dict, locals py.Mapping) (py.Object, error) {
+	var cache py.CompileCache
+	if thread != nil {
+		cache = thread.Cache
+	}
+	code, err := py.CompileCached(cache, src, filename, mode, flags)
+	if err != nil {
+		return nil, err
+	}
+	return EvalCodeWithContext(thread, code, globals, locals)
+}
+
+// PrintWithContext joins args the way the print() builtin does (values
+// separated by a space, followed by a newline) and writes the result
+// through thread.Print if set, falling back to stdout otherwise. Embedders
+// sandboxing untrusted code call this - or plumb it into their own
+// replacement for the print() builtin - instead of relying on the
+// unsandboxed print() builtin to reach stdout directly.
+func PrintWithContext(thread *py.Context, args py.Tuple) error {
+	var out []byte
+	for i, a := range args {
+		if i > 0 {
+			out = append(out, ' ')
+		}
+		s, err := py.Str(a)
+		if err != nil {
+			return err
+		}
+		out = append(out, string(s.(py.String))...)
+	}
+	out = append(out, '\n')
+	return thread.WritePrint(string(out))
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 The go-python Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vm
+
+import (
+	"testing"
+
+	"github.com/go-python/gpython/py"
+)
+
+// fakeMapping is a minimal py.Mapping backed by a py.Dict, recording every
+// key M__setitem__/M__delitem__ is called with so tests can assert exactly
+// which keys writeMappingBack touched.
+type fakeMapping struct {
+	data py.Dict
+	sets []py.Object
+	dels []py.Object
+}
+
+func newFakeMapping() *fakeMapping {
+	return &fakeMapping{data: py.NewDict()}
+}
+
+func (m *fakeMapping) set(key, value py.Object) {
+	m.data.Set(key, value)
+}
+
+func (m *fakeMapping) M__getitem__(key py.Object) (py.Object, error) {
+	v, ok, err := m.data.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, py.ExceptionNewf(py.KeyError, "%v", key)
+	}
+	return v, nil
+}
+
+func (m *fakeMapping) M__setitem__(key, value py.Object) (py.Object, error) {
+	m.sets = append(m.sets, key)
+	if err := m.data.Set(key, value); err != nil {
+		return nil, err
+	}
+	return py.None, nil
+}
+
+func (m *fakeMapping) M__delitem__(key py.Object) (py.Object, error) {
+	m.dels = append(m.dels, key)
+	ok, err := m.data.Del(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, py.ExceptionNewf(py.KeyError, "%v", key)
+	}
+	return py.None, nil
+}
+
+func (m *fakeMapping) M__contains__(key py.Object) (py.Object, error) {
+	_, ok, err := m.data.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return py.True, nil
+	}
+	return py.False, nil
+}
+
+func (m *fakeMapping) M__iter__() (py.Object, error) {
+	return py.NewIterator(m.data.Keys()), nil
+}
+
+var _ py.Mapping = (*fakeMapping)(nil)
+
+func TestCopyMappingInRoundTrips(t *testing.T) {
+	locals := newFakeMapping()
+	locals.set(py.String("a"), py.Int(1))
+	locals.set(py.String("b"), py.Int(2))
+
+	thread := py.NewContext(nil)
+	shadow, before, err := copyMappingIn(thread, locals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []py.Dict{shadow, before} {
+		if d.Len() != 2 {
+			t.Fatalf("got %d entries, want 2", d.Len())
+		}
+		if v, ok, _ := d.Get(py.String("a")); !ok || v != py.Int(1) {
+			t.Fatalf("Get(\"a\") = %v, %v, want 1, true", v, ok)
+		}
+		if v, ok, _ := d.Get(py.String("b")); !ok || v != py.Int(2) {
+			t.Fatalf("Get(\"b\") = %v, %v, want 2, true", v, ok)
+		}
+	}
+}
+
+func TestWriteMappingBackSkipsUnchangedKeys(t *testing.T) {
+	locals := newFakeMapping()
+	locals.set(py.String("a"), py.Int(1))
+	locals.set(py.String("b"), py.Int(2))
+
+	thread := py.NewContext(nil)
+	before := py.NewDict()
+	before.Set(py.String("a"), py.Int(1))
+	before.Set(py.String("b"), py.Int(2))
+
+	// after: "a" unchanged, "b" changed, "c" newly added.
+	after := py.NewDict()
+	after.Set(py.String("a"), py.Int(1))
+	after.Set(py.String("b"), py.Int(99))
+	after.Set(py.String("c"), py.Int(3))
+
+	if err := writeMappingBack(thread, locals, before, after); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range locals.sets {
+		if key == py.String("a") {
+			t.Fatalf("writeMappingBack wrote back \"a\", which was unchanged")
+		}
+	}
+	if v, ok, _ := locals.data.Get(py.String("b")); !ok || v != py.Int(99) {
+		t.Fatalf("locals[\"b\"] = %v, %v, want 99, true", v, ok)
+	}
+	if v, ok, _ := locals.data.Get(py.String("c")); !ok || v != py.Int(3) {
+		t.Fatalf("locals[\"c\"] = %v, %v, want 3, true (a new key from the run)", v, ok)
+	}
+}
+
+func TestWriteMappingBackDeletesMissingKeys(t *testing.T) {
+	locals := newFakeMapping()
+	locals.set(py.String("a"), py.Int(1))
+	locals.set(py.String("b"), py.Int(2))
+
+	thread := py.NewContext(nil)
+	before := py.NewDict()
+	before.Set(py.String("a"), py.Int(1))
+	before.Set(py.String("b"), py.Int(2))
+
+	// after: "b" is gone, as if the run had done `del b`.
+	after := py.NewDict()
+	after.Set(py.String("a"), py.Int(1))
+
+	if err := writeMappingBack(thread, locals, before, after); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(locals.dels) != 1 || locals.dels[0] != py.String("b") {
+		t.Fatalf("deleted keys = %v, want just \"b\"", locals.dels)
+	}
+	if _, ok, _ := locals.data.Get(py.String("b")); ok {
+		t.Fatalf("locals still has \"b\" after writeMappingBack deleted it")
+	}
+}
+
+func newBenchNamespace() py.Dict {
+	ns := py.NewDict()
+	ns.Set(py.String("a"), py.Int(1))
+	ns.Set(py.String("b"), py.Int(2))
+	ns.Set(py.String("c"), py.Int(3))
+	return ns
+}
+
+// BenchmarkEvalCached is eval("a+b*c", ns) run 10k times (go test
+// -bench=EvalCached -benchtime=10000x) against a thread whose Cache is
+// shared across calls, so the expression is parsed and compiled once and
+// then just run 10k times.
+func BenchmarkEvalCached(b *testing.B) {
+	thread := py.NewContext(nil)
+	thread.Cache = py.NewLRUCompileCache(16)
+	ns := newBenchNamespace()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := EvalSourceWithContext(thread, "a+b*c", "<string>", "eval", 0, ns, ns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEvalUncached is the same eval("a+b*c", ns) run 10k times, but
+// with no shared cache, so every call pays the full parse+compile cost -
+// the baseline BenchmarkEvalCached is meant to beat.
+func BenchmarkEvalUncached(b *testing.B) {
+	ns := newBenchNamespace()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		thread := py.NewContext(nil)
+		thread.Cache = py.NewLRUCompileCache(16)
+		if _, err := EvalSourceWithContext(thread, "a+b*c", "<string>", "eval", 0, ns, ns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}